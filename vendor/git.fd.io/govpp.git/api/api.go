@@ -15,8 +15,11 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -80,8 +83,9 @@ type MessageIdentifier interface {
 
 // Channel is the main communication interface with govpp core. It contains two Go channels, one for sending the requests
 // to VPP and one for receiving the replies from it. The user can access the Go channels directly, or use the helper
-// methods  provided inside of this package. Do not use the same channel from multiple goroutines concurrently,
-// otherwise the responses could mix! Use multiple channels instead.
+// methods provided inside of this package. Each request is tagged with a unique context ID and demultiplexed back to
+// the RequestCtx/MultiRequestCtx that sent it via a per-request reply channel, so a single Channel can be shared
+// safely by multiple goroutines - there is no need to keep a pool of channels around just for concurrency.
 type Channel struct {
 	ReqChan   chan *VppRequest // channel for sending the requests to VPP, closing this channel releases all resources in the ChannelProvider
 	ReplyChan chan *VppReply   // channel where VPP replies are delivered to
@@ -94,12 +98,62 @@ type Channel struct {
 
 	replyTimeout time.Duration // maximum time that the API waits for a reply from VPP before returning an error, can be set with SetReplyTimeout
 	metadata     interface{}   // opaque metadata of the API channel
+
+	nextContextID uint32                    // incremented atomically to generate a unique context ID for each request
+	demuxOnce     sync.Once                 // guards the one-time start of the demux goroutine
+	repliesLock   sync.Mutex                // guards replies
+	replies       map[uint32]chan *VppReply // per-request reply channels, keyed by the request's context ID
+
+	pingRequest      Message        // concrete control-ping request message, set via SetPingMessages
+	pingReplyFactory func() Message // factory for the control-ping reply message, set via SetPingMessages
+
+	healthLock  sync.Mutex         // guards healthState and healthSubs
+	healthState HealthState        // current health of the channel, updated by the keepalive goroutine started by StartHealthCheck
+	healthSubs  []chan HealthEvent // subscribers registered via WatchHealthState
+}
+
+// HealthState represents the health of a Channel as observed by its keepalive goroutine (see StartHealthCheck).
+type HealthState int
+
+const (
+	// Healthy is the default state; the most recent Ping succeeded.
+	Healthy HealthState = iota
+	// Reconnecting means one or more recent Pings failed, but fewer than maxConsecutivePingFailures in a row, so
+	// VPP may just be briefly unresponsive or restarting.
+	Reconnecting
+	// Disconnected means maxConsecutivePingFailures consecutive Pings have failed, so VPP is presumed actually
+	// gone rather than just slow to answer one ping.
+	Disconnected
+)
+
+// maxConsecutivePingFailures is the number of consecutive failed keepalive pings after which HealthState escalates
+// from Reconnecting to Disconnected.
+const maxConsecutivePingFailures = 3
+
+// String returns a human-readable representation of the health state.
+func (s HealthState) String() string {
+	switch s {
+	case Healthy:
+		return "Healthy"
+	case Reconnecting:
+		return "Reconnecting"
+	case Disconnected:
+		return "Disconnected"
+	default:
+		return "Unknown"
+	}
+}
+
+// HealthEvent is delivered to subscribers registered via WatchHealthState whenever the channel's HealthState changes.
+type HealthEvent struct {
+	State HealthState // the new health state
 }
 
 // VppRequest is a request that will be sent to VPP.
 type VppRequest struct {
 	Message   Message // binary API message to be send to VPP
 	Multipart bool    // true if multipart response is expected, false otherwise
+	ContextID uint32  // context ID used to correlate the reply (or replies) with this request
 }
 
 // VppReply is a reply received from VPP.
@@ -108,6 +162,7 @@ type VppReply struct {
 	Data              []byte // encoded data with the message - MessageDecoder can be used for decoding
 	LastReplyReceived bool   // in case of multipart replies, true if the last reply has been already received and this one should be ignored
 	Error             error  // in case of error, data is nil and this member contains error description
+	ContextID         uint32 // context ID of the request this reply belongs to, copied from the originating VppRequest
 }
 
 // NotifSubscribeRequest is a request to subscribe for delivery of specific notification messages.
@@ -124,16 +179,25 @@ type NotifSubscription struct {
 
 // RequestCtx is a context of a ongoing request (simple one - only one response is expected).
 type RequestCtx struct {
-	ch *Channel
+	ch        *Channel
+	contextID uint32
+	replyChan chan *VppReply
 }
 
 // MultiRequestCtx is a context of a ongoing multipart request (multiple responses are expected).
 type MultiRequestCtx struct {
-	ch *Channel
+	ch        *Channel
+	contextID uint32
+	replyChan chan *VppReply
 }
 
 const defaultReplyTimeout = time.Second * 1 // default timeout for replies from VPP, can be changed with SetReplyTimeout
 
+// requestReplyChanBufSize is the buffer size of the per-request reply channel created for every RequestCtx/
+// MultiRequestCtx, sized to comfortably hold a burst of multipart replies without blocking the deliverReply
+// goroutines that hand them over.
+const requestReplyChanBufSize = 4
+
 // NewChannelInternal returns a new channel structure with metadata field filled in with the provided argument.
 // Note that this is just a raw channel not yet connected to VPP, it is not intended to be used directly.
 // Use ChannelProvider to get an API channel ready for communication with VPP.
@@ -141,6 +205,7 @@ func NewChannelInternal(metadata interface{}) *Channel {
 	return &Channel{
 		replyTimeout: defaultReplyTimeout,
 		metadata:     metadata,
+		replies:      make(map[uint32]chan *VppReply),
 	}
 }
 
@@ -164,23 +229,48 @@ func (ch *Channel) Close() {
 
 // SendRequest asynchronously sends a request to VPP. Returns a request context, that can be used to call ReceiveReply.
 // In case of any errors by sending, the error will be delivered to ReplyChan (and returned by ReceiveReply).
+// It is safe to call SendRequest concurrently from multiple goroutines on the same Channel.
 func (ch *Channel) SendRequest(msg Message) *RequestCtx {
+	contextID, replyChan := ch.newRequestContext()
 	ch.ReqChan <- &VppRequest{
-		Message: msg,
+		Message:   msg,
+		ContextID: contextID,
+	}
+	return &RequestCtx{ch: ch, contextID: contextID, replyChan: replyChan}
+}
+
+// SendRequestContext asynchronously sends a request to VPP, same as SendRequest, except the send onto ReqChan
+// itself also honors ctx: if ctx is done before the request can be enqueued (e.g. the core has stopped draining
+// ReqChan), SendRequestContext gives up on sending and returns immediately instead of blocking forever. The
+// returned RequestCtx's ReceiveReplyContext will then report ctx.Err() rather than waiting for a reply that was
+// never actually sent.
+func (ch *Channel) SendRequestContext(ctx context.Context, msg Message) *RequestCtx {
+	contextID, replyChan := ch.newRequestContext()
+	select {
+	case ch.ReqChan <- &VppRequest{Message: msg, ContextID: contextID}:
+	case <-ctx.Done():
+		ch.releaseRequestContext(contextID)
 	}
-	return &RequestCtx{ch: ch}
+	return &RequestCtx{ch: ch, contextID: contextID, replyChan: replyChan}
 }
 
 // ReceiveReply receives a reply from VPP (blocks until a reply is delivered from VPP, or until an error occurs).
 // The reply will be decoded into the msg argument. Error will be returned if the response cannot be received or decoded.
 func (req *RequestCtx) ReceiveReply(msg Message) error {
+	return req.ReceiveReplyContext(context.Background(), msg)
+}
+
+// ReceiveReplyContext is like ReceiveReply, but also returns ctx.Err() as soon as ctx is done, instead of waiting
+// out the full reply timeout.
+func (req *RequestCtx) ReceiveReplyContext(ctx context.Context, msg Message) error {
 	if req == nil || req.ch == nil {
 		return errors.New("invalid request context")
 	}
+	defer req.ch.releaseRequestContext(req.contextID)
 
-	lastReplyReceived, err := req.ch.receiveReplyInternal(msg)
+	lastReplyReceived, err := req.ch.receiveReplyInternal(ctx, req.replyChan, msg)
 
-	if lastReplyReceived {
+	if err == nil && lastReplyReceived {
 		err = errors.New("multipart reply recieved while a simple reply expected")
 	}
 	return err
@@ -189,12 +279,15 @@ func (req *RequestCtx) ReceiveReply(msg Message) error {
 // SendMultiRequest asynchronously sends a multipart request (request to which multiple responses are expected) to VPP.
 // Returns a multipart request context, that can be used to call ReceiveReply.
 // In case of any errors by sending, the error will be delivered to ReplyChan (and returned by ReceiveReply).
+// It is safe to call SendMultiRequest concurrently from multiple goroutines on the same Channel.
 func (ch *Channel) SendMultiRequest(msg Message) *MultiRequestCtx {
+	contextID, replyChan := ch.newRequestContext()
 	ch.ReqChan <- &VppRequest{
 		Message:   msg,
 		Multipart: true,
+		ContextID: contextID,
 	}
-	return &MultiRequestCtx{ch: ch}
+	return &MultiRequestCtx{ch: ch, contextID: contextID, replyChan: replyChan}
 }
 
 // ReceiveReply receives a reply from VPP (blocks until a reply is delivered from VPP, or until an error occurs).
@@ -202,21 +295,33 @@ func (ch *Channel) SendMultiRequest(msg Message) *MultiRequestCtx {
 // set to true. Do not use the message itself if LastReplyReceived is true - it won't be filled with actual data.
 // Error will be returned if the response cannot be received or decoded.
 func (req *MultiRequestCtx) ReceiveReply(msg Message) (LastReplyReceived bool, err error) {
+	return req.ReceiveReplyContext(context.Background(), msg)
+}
+
+// ReceiveReplyContext is like ReceiveReply, but also returns ctx.Err() as soon as ctx is done, instead of waiting
+// out the full reply timeout. Once ctx is done (or any other error occurs), the request context is released: the
+// remainder of the multipart stream is dropped by demux rather than read until LastReplyReceived, and since every
+// request has its own reply channel, the next request on this channel does not pick up stale replies.
+func (req *MultiRequestCtx) ReceiveReplyContext(ctx context.Context, msg Message) (LastReplyReceived bool, err error) {
 	if req == nil || req.ch == nil {
 		return false, errors.New("invalid request context")
 	}
 
-	return req.ch.receiveReplyInternal(msg)
+	LastReplyReceived, err = req.ch.receiveReplyInternal(ctx, req.replyChan, msg)
+	if LastReplyReceived || err != nil {
+		req.ch.releaseRequestContext(req.contextID)
+	}
+	return
 }
 
-// receiveReplyInternal receives a reply from the reply channel into the provided msg structure.
-func (ch *Channel) receiveReplyInternal(msg Message) (LastReplyReceived bool, err error) {
+// receiveReplyInternal receives a reply from replyChan into the provided msg structure.
+func (ch *Channel) receiveReplyInternal(ctx context.Context, replyChan chan *VppReply, msg Message) (LastReplyReceived bool, err error) {
 	if msg == nil {
 		return false, errors.New("nil message passed in")
 	}
 	select {
-	// blocks until a reply comes to ReplyChan or until timeout expires
-	case vppReply := <-ch.ReplyChan:
+	// blocks until a reply comes to replyChan, ctx is done, or until timeout expires
+	case vppReply := <-replyChan:
 		if vppReply.Error != nil {
 			err = vppReply.Error
 			return
@@ -233,19 +338,143 @@ func (ch *Channel) receiveReplyInternal(msg Message) (LastReplyReceived bool, er
 			return false, err
 		}
 		if vppReply.MessageID != expMsgID {
-			err = fmt.Errorf("received invalid message ID, expected %d (%s), but got %d (check if multiple goroutines are not sharing single GoVPP channel)",
+			err = fmt.Errorf("received invalid message ID, expected %d (%s), but got %d",
 				expMsgID, msg.GetMessageName(), vppReply.MessageID)
 			return false, err
 		}
 		// decode the message
 		err = ch.MsgDecoder.DecodeMsg(vppReply.Data, msg)
 
+	case <-ctx.Done():
+		err = ctx.Err()
+
 	case <-time.After(ch.replyTimeout):
 		err = fmt.Errorf("no reply received within the timeout period %s", ch.replyTimeout)
 	}
 	return
 }
 
+// newRequestContext allocates a unique context ID for a new request, registers a reply channel for it in replies
+// so demux can deliver the matching VppReply(-ies) to it, and lazily starts the demux goroutine.
+func (ch *Channel) newRequestContext() (contextID uint32, replyChan chan *VppReply) {
+	ch.demuxOnce.Do(func() { go ch.demux() })
+
+	contextID = atomic.AddUint32(&ch.nextContextID, 1)
+	replyChan = make(chan *VppReply, requestReplyChanBufSize)
+
+	ch.repliesLock.Lock()
+	ch.replies[contextID] = replyChan
+	ch.repliesLock.Unlock()
+
+	return contextID, replyChan
+}
+
+// releaseRequestContext removes the reply channel for contextID from replies, so that any further VppReply for it
+// (a straggler from a cancelled multipart stream, for instance) is dropped by demux instead of piling up. Since a
+// reply may already be in flight to the channel at the moment it's removed, a background drain with a bounded
+// lifetime takes one reply off the channel, unblocking one deliverReply send; any other blocked send gives up
+// after replyTimeout.
+func (ch *Channel) releaseRequestContext(contextID uint32) {
+	ch.repliesLock.Lock()
+	replyChan, ok := ch.replies[contextID]
+	delete(ch.replies, contextID)
+	ch.repliesLock.Unlock()
+
+	if !ok {
+		return
+	}
+	go func() {
+		select {
+		case <-replyChan:
+		case <-time.After(ch.replyTimeout):
+		}
+	}()
+}
+
+// demux reads every VppReply delivered on the shared ReplyChan and hands each one off to deliverReply, which
+// forwards it to the per-request reply channel registered for its ContextID, so concurrently issued requests never
+// see each other's replies. It runs for the lifetime of the Channel, exiting once ReplyChan is closed.
+//
+// demux itself never blocks on a per-request channel: delivery happens in its own goroutine, so a caller that
+// stops calling ReceiveReply part way through (without cancelling its context, e.g. it decided a multipart dump
+// already has "enough") only delays its own reply - it can no longer hold up this loop's ability to keep
+// dequeuing ReplyChan, and so never stalls replies that are already ready for every other concurrent caller.
+func (ch *Channel) demux() {
+	for vppReply := range ch.ReplyChan {
+		ch.repliesLock.Lock()
+		replyChan, ok := ch.replies[vppReply.ContextID]
+		ch.repliesLock.Unlock()
+		if !ok {
+			// no longer interested in this reply, e.g. its request context was already released
+			continue
+		}
+		go ch.deliverReply(vppReply.ContextID, replyChan, vppReply)
+	}
+}
+
+// deliverReply hands vppReply to replyChan, bounded by replyTimeout. If it can't be delivered in time - because
+// the request's caller has stopped reading, e.g. an abandoned multipart consumer - the reply is dropped and the
+// now-presumed-abandoned request context is reaped.
+func (ch *Channel) deliverReply(contextID uint32, replyChan chan *VppReply, vppReply *VppReply) {
+	select {
+	case replyChan <- vppReply:
+	case <-time.After(ch.replyTimeout):
+		ch.releaseRequestContext(contextID)
+	}
+}
+
+// BatchCtx is a context of an ongoing batch of requests queued together via SendBatch. It preserves the order in
+// which the requests were sent, so ReceiveReplies can match each reply back to its request.
+type BatchCtx struct {
+	reqCtxs []*RequestCtx
+}
+
+// SendBatch queues every message in msgs onto ReqChan, in order, and returns a BatchCtx that can be used to collect
+// their replies via ReceiveReplies. Unlike sending the messages one by one and waiting for each reply in turn,
+// SendBatch lets the core pipeline all the sends up front, which matters when pushing a high volume of
+// configuration (e.g. thousands of ACL or route entries) where per-message round-trip latency would otherwise
+// dominate.
+func (ch *Channel) SendBatch(msgs []Message) (*BatchCtx, error) {
+	if len(msgs) == 0 {
+		return nil, errors.New("no messages to send")
+	}
+	reqCtxs := make([]*RequestCtx, len(msgs))
+	for i, msg := range msgs {
+		reqCtxs[i] = ch.SendRequest(msg)
+	}
+	return &BatchCtx{reqCtxs: reqCtxs}, nil
+}
+
+// ReceiveReplies receives the reply for every request queued by SendBatch, in the same order, decoding each one
+// into the corresponding element of out. len(out) must equal the number of messages passed to SendBatch.
+//
+// Every request in the batch is always drained via ReceiveReply, even once an earlier one has errored: bailing out
+// early would leave the later requests' contexts registered in the channel forever, with their replies delivered
+// but never consumed. The first error encountered is returned.
+func (batch *BatchCtx) ReceiveReplies(out []Message) error {
+	if batch == nil {
+		return errors.New("invalid batch context")
+	}
+	if len(out) != len(batch.reqCtxs) {
+		return fmt.Errorf("expected %d replies, got %d destination messages", len(batch.reqCtxs), len(out))
+	}
+	var firstErr error
+	for i, reqCtx := range batch.reqCtxs {
+		if err := reqCtx.ReceiveReply(out[i]); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("batch request %d: %s", i, err)
+		}
+	}
+	return firstErr
+}
+
+// SendAndReceive sends req synchronously, then blocks until the reply is delivered and decoded into reply.
+// It is a convenience wrapper around SendRequest/ReceiveReply for the common request/reply pattern, so that a
+// caller does not have to pair up a SendRequest and a ReceiveReply by hand. It takes untyped Messages: choosing
+// the reply type that matches req is left to the caller, and no generated typed service stubs wrap it.
+func (ch *Channel) SendAndReceive(req, reply Message) error {
+	return ch.SendRequest(req).ReceiveReply(reply)
+}
+
 // SubscribeNotification subscribes for receiving of the specified notification messages via provided Go channel.
 // Note that the caller is responsible for creating the Go channel with preferred buffer size. If the channel's
 // buffer is full, the notifications will not be delivered into it.
@@ -282,3 +511,96 @@ func (ch *Channel) CheckMessageCompatibility(messages ...Message) error {
 	}
 	return nil
 }
+
+// SetPingMessages configures the concrete control-ping request message and reply factory used by Ping and by the
+// keepalive goroutine started via StartHealthCheck. It must be called (typically once, by the ChannelProvider,
+// right after the channel is created) before either of those is used, since the concrete binapi control-ping
+// messages are not known to this package.
+func (ch *Channel) SetPingMessages(request Message, replyFactory func() Message) {
+	ch.pingRequest = request
+	ch.pingReplyFactory = replyFactory
+}
+
+// Ping sends a VPP control-ping request and blocks until the reply is received or ctx is done. It lets long-lived
+// applications detect a hung or restarted VPP without waiting for the next real request to time out.
+func (ch *Channel) Ping(ctx context.Context) error {
+	if ch.pingRequest == nil || ch.pingReplyFactory == nil {
+		return errors.New("ping messages not configured, call SetPingMessages first")
+	}
+	return ch.SendRequestContext(ctx, ch.pingRequest).ReceiveReplyContext(ctx, ch.pingReplyFactory())
+}
+
+// HealthState returns the channel's current health, as last observed by the keepalive goroutine started via
+// StartHealthCheck.
+func (ch *Channel) HealthState() HealthState {
+	ch.healthLock.Lock()
+	defer ch.healthLock.Unlock()
+	return ch.healthState
+}
+
+// WatchHealthState registers healthChan to receive a HealthEvent whenever the channel's HealthState changes.
+// The caller is responsible for creating the channel with a preferred buffer size; if its buffer is full, the
+// event is dropped, mirroring how SubscribeNotification treats notification channels.
+func (ch *Channel) WatchHealthState(healthChan chan HealthEvent) {
+	ch.healthLock.Lock()
+	ch.healthSubs = append(ch.healthSubs, healthChan)
+	ch.healthLock.Unlock()
+}
+
+// setHealthState updates the channel's health state and notifies subscribers if it actually changed.
+func (ch *Channel) setHealthState(state HealthState) {
+	ch.healthLock.Lock()
+	changed := ch.healthState != state
+	ch.healthState = state
+	subs := ch.healthSubs
+	ch.healthLock.Unlock()
+
+	if !changed {
+		return
+	}
+	for _, sub := range subs {
+		select {
+		case sub <- HealthEvent{State: state}:
+		default:
+		}
+	}
+}
+
+// StartHealthCheck launches a background goroutine that calls Ping every interval, updating HealthState (and
+// notifying subscribers registered via WatchHealthState) with the outcome, until ctx is done. SetPingMessages
+// must be called before the first ping is due. Once ctx is done, the goroutine simply stops - it does not set
+// HealthState to Disconnected, since that value is reserved for a VPP confirmed gone by repeated ping failures,
+// not for "nobody is watching anymore".
+func (ch *Channel) StartHealthCheck(ctx context.Context, interval time.Duration) {
+	go ch.healthCheckLoop(ctx, interval)
+}
+
+func (ch *Channel) healthCheckLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var consecutiveFailures int
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, ch.replyTimeout)
+			err := ch.Ping(pingCtx)
+			cancel()
+
+			if err != nil {
+				consecutiveFailures++
+				if consecutiveFailures >= maxConsecutivePingFailures {
+					ch.setHealthState(Disconnected)
+				} else {
+					ch.setHealthState(Reconnecting)
+				}
+			} else {
+				consecutiveFailures = 0
+				ch.setHealthState(Healthy)
+			}
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2017 Cisco and/or its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testMessage struct{ name string }
+
+func (m *testMessage) GetMessageName() string      { return m.name }
+func (m *testMessage) GetMessageType() MessageType { return ReplyMessage }
+func (m *testMessage) GetCrcString() string        { return "crc" }
+
+type testDecoder struct{}
+
+func (testDecoder) DecodeMsg(data []byte, msg Message) error { return nil }
+
+type testIdentifier struct{ id uint16 }
+
+func (t testIdentifier) GetMessageID(msg Message) (uint16, error) { return t.id, nil }
+
+// newTestChannel returns a Channel wired up with fake decoder/identifier and a short reply timeout, ready to have
+// VppRequest/VppReply traffic driven through it directly in tests.
+func newTestChannel(bufSize int) *Channel {
+	ch := NewChannelInternal(nil)
+	ch.ReqChan = make(chan *VppRequest, bufSize)
+	ch.ReplyChan = make(chan *VppReply, bufSize)
+	ch.MsgDecoder = testDecoder{}
+	ch.MsgIdentifier = testIdentifier{id: 1}
+	ch.SetReplyTimeout(50 * time.Millisecond)
+	return ch
+}
+
+func TestChannelConcurrentRequests(t *testing.T) {
+	ch := newTestChannel(64)
+	defer close(ch.ReqChan)
+
+	go func() {
+		for req := range ch.ReqChan {
+			ch.ReplyChan <- &VppReply{MessageID: 1, ContextID: req.ContextID}
+		}
+	}()
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(n)
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			if err := ch.SendRequest(&testMessage{name: "req"}).ReceiveReply(&testMessage{}); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected error from concurrent SendRequest/ReceiveReply: %v", err)
+	}
+}
+
+func TestDemuxDoesNotStallOnAbandonedRequest(t *testing.T) {
+	ch := newTestChannel(16)
+	defer close(ch.ReqChan)
+
+	abandoned := ch.SendRequest(&testMessage{name: "abandoned"})
+	// never call ReceiveReply on abandoned - flood enough replies to overflow its bounded reply channel
+	for i := 0; i < requestReplyChanBufSize+1; i++ {
+		ch.ReplyChan <- &VppReply{MessageID: 1, ContextID: abandoned.contextID}
+	}
+
+	// give the blocked delivery time to give up on the abandoned (never-drained) request and reap its context,
+	// so the fresh request below is served after that reaping has happened.
+	time.Sleep(3 * ch.replyTimeout)
+
+	unrelated := ch.SendRequest(&testMessage{name: "unrelated"})
+	ch.ReplyChan <- &VppReply{MessageID: 1, ContextID: unrelated.contextID}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- unrelated.ReceiveReply(&testMessage{})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("demux stalled delivering to an abandoned request and never reached an unrelated reply")
+	}
+}
+
+func TestDemuxDoesNotDelayUnrelatedRepliesBehindAbandonedRequest(t *testing.T) {
+	ch := newTestChannel(16)
+	defer close(ch.ReqChan)
+
+	abandoned := ch.SendRequest(&testMessage{name: "abandoned"})
+	// never call ReceiveReply on abandoned - flood enough replies to overflow its bounded reply channel, so
+	// demux is still trying (and failing) to deliver to it when the unrelated reply below is ready.
+	for i := 0; i < requestReplyChanBufSize+1; i++ {
+		ch.ReplyChan <- &VppReply{MessageID: 1, ContextID: abandoned.contextID}
+	}
+
+	unrelated := ch.SendRequest(&testMessage{name: "unrelated"})
+	ch.ReplyChan <- &VppReply{MessageID: 1, ContextID: unrelated.contextID}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- unrelated.ReceiveReply(&testMessage{})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed >= ch.replyTimeout {
+			t.Fatalf("unrelated reply took %s to arrive, as long as the abandoned request's own reply timeout - "+
+				"demux is still serializing delivery behind the stuck consumer instead of delivering concurrently",
+				elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("demux stalled delivering to an abandoned request and never reached an unrelated reply")
+	}
+}
+
+func TestSendRequestContextGivesUpWhenSendBlocked(t *testing.T) {
+	ch := newTestChannel(0) // unbuffered ReqChan, and nothing ever drains it - simulates a stuck core
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	req := ch.SendRequestContext(ctx, &testMessage{name: "req"})
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("SendRequestContext blocked for %s, well past its context's deadline", elapsed)
+	}
+
+	if err := req.ReceiveReplyContext(ctx, &testMessage{}); err == nil {
+		t.Fatal("expected an error, since the request was never actually sent")
+	}
+}
+
+func TestReceiveReplyContextRespectsCancellation(t *testing.T) {
+	ch := newTestChannel(4)
+	defer close(ch.ReqChan)
+
+	go func() {
+		for range ch.ReqChan {
+			// simulate a VPP that never replies
+		}
+	}()
+
+	req := ch.SendRequest(&testMessage{name: "req"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := req.ReceiveReplyContext(ctx, &testMessage{})
+	elapsed := time.Since(start)
+
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed >= ch.replyTimeout {
+		t.Fatalf("ReceiveReplyContext took %s to return; it should return as soon as ctx is cancelled, well "+
+			"under the %s reply timeout", elapsed, ch.replyTimeout)
+	}
+}
+
+func TestSendBatchReleasesAllContextsOnError(t *testing.T) {
+	ch := newTestChannel(16)
+	defer close(ch.ReqChan)
+
+	msgs := []Message{&testMessage{name: "a"}, &testMessage{name: "b"}, &testMessage{name: "c"}}
+	batch, err := ch.SendBatch(msgs)
+	if err != nil {
+		t.Fatalf("SendBatch failed: %v", err)
+	}
+
+	ch.ReplyChan <- &VppReply{ContextID: batch.reqCtxs[0].contextID, Error: errors.New("boom")}
+	ch.ReplyChan <- &VppReply{MessageID: 1, ContextID: batch.reqCtxs[1].contextID}
+	ch.ReplyChan <- &VppReply{MessageID: 1, ContextID: batch.reqCtxs[2].contextID}
+
+	out := make([]Message, len(msgs))
+	for i := range out {
+		out[i] = &testMessage{}
+	}
+
+	if err := batch.ReceiveReplies(out); err == nil {
+		t.Fatal("expected ReceiveReplies to return the first request's error")
+	}
+
+	ch.repliesLock.Lock()
+	remaining := len(ch.replies)
+	ch.repliesLock.Unlock()
+	if remaining != 0 {
+		t.Fatalf("expected every batched request context to be released, got %d still registered", remaining)
+	}
+}
+
+func TestPingRespectsContextWhenCoreIsStuck(t *testing.T) {
+	ch := newTestChannel(0) // unbuffered ReqChan, and nothing ever drains it - simulates a hung VPP/core
+	ch.SetPingMessages(&testMessage{name: "ping"}, func() Message { return &testMessage{} })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := ch.Ping(ctx)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected Ping to fail, since nothing ever drains ReqChan")
+	}
+	if elapsed > 200*time.Millisecond {
+		t.Fatalf("Ping blocked for %s, well past its context's deadline - it should give up as soon as ctx is done", elapsed)
+	}
+}
+
+func TestHealthCheckEscalatesToDisconnectedAfterRepeatedFailures(t *testing.T) {
+	ch := newTestChannel(4)
+	defer close(ch.ReqChan)
+	// ch.pingRequest/pingReplyFactory are intentionally left unset, so every Ping attempt fails instantly and
+	// deterministically, without needing a fake VPP on the other end of ReqChan/ReplyChan.
+
+	events := make(chan HealthEvent, 8)
+	ch.WatchHealthState(events)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch.StartHealthCheck(ctx, 5*time.Millisecond)
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.State == Healthy {
+				t.Fatal("Ping was never configured to succeed, HealthState should never report Healthy")
+			}
+			if ev.State == Disconnected {
+				if got := ch.HealthState(); got != Disconnected {
+					t.Fatalf("expected HealthState() to report Disconnected, got %s", got)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("HealthState never escalated to Disconnected after repeated ping failures")
+		}
+	}
+}